@@ -0,0 +1,152 @@
+// Copyright 2021, OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objmodel
+
+import (
+	"io"
+
+	"github.com/elastic/go-structform"
+	"github.com/elastic/go-structform/json"
+)
+
+// OpType is the Elasticsearch _bulk API action type for a single document.
+type OpType uint8
+
+const (
+	// OpTypeIndex indexes the document, creating or overwriting it.
+	OpTypeIndex OpType = iota
+	// OpTypeCreate indexes the document only if it doesn't already exist.
+	OpTypeCreate
+	// OpTypeUpdate partially updates an existing document.
+	OpTypeUpdate
+)
+
+func (op OpType) String() string {
+	switch op {
+	case OpTypeCreate:
+		return "create"
+	case OpTypeUpdate:
+		return "update"
+	default:
+		return "index"
+	}
+}
+
+// BulkMeta holds the per-document metadata that the _bulk API expects on the
+// action line preceding a document's source.
+type BulkMeta struct {
+	Index      string
+	DocumentID string
+	Op         OpType
+}
+
+// BulkEncoder writes a batch of Documents to the Elasticsearch _bulk API's
+// newline-delimited JSON format: an action line followed by the document's
+// source, repeated for each document. It reuses a single go-structform/json
+// visitor across the whole batch, so encoding a batch allocates no
+// per-document intermediate buffers.
+type BulkEncoder struct {
+	w     io.Writer
+	vis   structform.ExtVisitor
+	dedot bool
+}
+
+// NewBulkEncoder creates a BulkEncoder that writes to w.
+func NewBulkEncoder(w io.Writer) *BulkEncoder {
+	return &BulkEncoder{w: w, vis: json.NewVisitor(w)}
+}
+
+// SetDedot controls whether Encode serializes documents using the nested
+// (dedot) JSON mode instead of the default flat, dot-delimited mode.
+func (e *BulkEncoder) SetDedot(dedot bool) {
+	e.dedot = dedot
+}
+
+// Encode writes meta's action line followed by doc's source, each on its own
+// line. doc must already be sorted and deduplicated. A zero-length Document
+// is encoded as an empty JSON object ("{}"), since the _bulk API doesn't
+// accept the "null" source that Document.Serialize would otherwise produce
+// for an empty document.
+func (e *BulkEncoder) Encode(meta BulkMeta, doc Document) error {
+	if err := e.encodeAction(meta); err != nil {
+		return err
+	}
+	if err := e.newline(); err != nil {
+		return err
+	}
+
+	if hasVisibleField(doc.fields) {
+		if err := doc.iterJSON(e.vis, e.dedot); err != nil {
+			return err
+		}
+	} else if err := e.emptyObject(); err != nil {
+		return err
+	}
+
+	return e.newline()
+}
+
+// Close closes the underlying writer if it implements io.Closer; otherwise
+// it does nothing. The encoder itself holds no buffered output.
+func (e *BulkEncoder) Close() error {
+	if c, ok := e.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (e *BulkEncoder) encodeAction(meta BulkMeta) error {
+	if err := e.vis.OnObjectStart(-1, structform.AnyType); err != nil {
+		return err
+	}
+	if err := e.vis.OnKey(meta.Op.String()); err != nil {
+		return err
+	}
+	if err := e.vis.OnObjectStart(-1, structform.AnyType); err != nil {
+		return err
+	}
+	if meta.Index != "" {
+		if err := e.vis.OnKey("_index"); err != nil {
+			return err
+		}
+		if err := e.vis.OnString(meta.Index); err != nil {
+			return err
+		}
+	}
+	if meta.DocumentID != "" {
+		if err := e.vis.OnKey("_id"); err != nil {
+			return err
+		}
+		if err := e.vis.OnString(meta.DocumentID); err != nil {
+			return err
+		}
+	}
+	if err := e.vis.OnObjectFinished(); err != nil {
+		return err
+	}
+	return e.vis.OnObjectFinished()
+}
+
+func (e *BulkEncoder) emptyObject() error {
+	if err := e.vis.OnObjectStart(-1, structform.AnyType); err != nil {
+		return err
+	}
+	return e.vis.OnObjectFinished()
+}
+
+func (e *BulkEncoder) newline() error {
+	_, err := e.w.Write([]byte{'\n'})
+	return err
+}
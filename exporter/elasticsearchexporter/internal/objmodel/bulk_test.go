@@ -0,0 +1,132 @@
+// Copyright 2021, OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objmodel
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkEncoder_Encode(t *testing.T) {
+	tests := map[string]struct {
+		meta BulkMeta
+		doc  func() Document
+		want string
+	}{
+		"index with id": {
+			meta: BulkMeta{Index: "logs-generic", DocumentID: "1", Op: OpTypeIndex},
+			doc: func() (doc Document) {
+				doc.AddString("a", "b")
+				return doc
+			},
+			want: `{"index":{"_index":"logs-generic","_id":"1"}}` + "\n" + `{"a":"b"}` + "\n",
+		},
+		"create without id": {
+			meta: BulkMeta{Index: "logs-generic", Op: OpTypeCreate},
+			doc: func() (doc Document) {
+				doc.AddInt("n", 1)
+				return doc
+			},
+			want: `{"create":{"_index":"logs-generic"}}` + "\n" + `{"n":1}` + "\n",
+		},
+		"update": {
+			meta: BulkMeta{Index: "logs-generic", DocumentID: "42", Op: OpTypeUpdate},
+			doc: func() (doc Document) {
+				doc.Add("ok", BoolValue(true))
+				return doc
+			},
+			want: `{"update":{"_index":"logs-generic","_id":"42"}}` + "\n" + `{"ok":true}` + "\n",
+		},
+		"zero-length document encodes as an empty object": {
+			meta: BulkMeta{Index: "logs-generic", Op: OpTypeIndex},
+			doc:  func() Document { return Document{} },
+			want: `{"index":{"_index":"logs-generic"}}` + "\n" + `{}` + "\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewBulkEncoder(&buf)
+			require.NoError(t, enc.Encode(test.meta, test.doc()))
+			assert.Equal(t, test.want, buf.String())
+		})
+	}
+}
+
+func TestBulkEncoder_EncodeMultipleDocuments(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewBulkEncoder(&buf)
+
+	var a, b Document
+	a.AddInt("n", 1)
+	b.AddInt("n", 2)
+
+	require.NoError(t, enc.Encode(BulkMeta{Index: "idx", Op: OpTypeIndex}, a))
+	require.NoError(t, enc.Encode(BulkMeta{Index: "idx", Op: OpTypeIndex}, b))
+
+	want := `{"index":{"_index":"idx"}}` + "\n" + `{"n":1}` + "\n" +
+		`{"index":{"_index":"idx"}}` + "\n" + `{"n":2}` + "\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func benchDocuments(n int) []Document {
+	docs := make([]Document, n)
+	for i := range docs {
+		docs[i].AddString("message", "benchmark log line")
+		docs[i].AddInt("count", int64(i))
+		docs[i].AddString("service.name", "benchmark-service")
+	}
+	return docs
+}
+
+// BenchmarkSerialize_PerDocumentBuilder serializes each document into its
+// own strings.Builder, as the non-streaming Document.Serialize path does.
+func BenchmarkSerialize_PerDocumentBuilder(b *testing.B) {
+	docs := benchDocuments(100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range docs {
+			var buf strings.Builder
+			if err := docs[j].Serialize(&buf, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkBulkEncoder_Streaming encodes the same batch of documents through
+// a single BulkEncoder writing into one io.Writer, reusing one visitor.
+func BenchmarkBulkEncoder_Streaming(b *testing.B) {
+	docs := benchDocuments(100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		enc := NewBulkEncoder(io.Discard)
+		for j := range docs {
+			if err := enc.Encode(BulkMeta{Index: "bench", Op: OpTypeIndex}, docs[j]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
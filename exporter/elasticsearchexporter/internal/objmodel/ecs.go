@@ -0,0 +1,128 @@
+// Copyright 2021, OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objmodel
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// ecsAttributeKeys maps OpenTelemetry semantic-convention attribute keys to
+// their Elastic Common Schema (ECS) equivalents. Keys with no entry here
+// pass through ApplyECS unchanged.
+var ecsAttributeKeys = map[string]string{
+	"http.method":          "http.request.method",
+	"http.status_code":     "http.response.status_code",
+	"net.peer.ip":          "source.ip",
+	"service.name":         "service.name", // identity: already an ECS field
+	"exception.type":       "error.type",
+	"exception.message":    "error.message",
+	"exception.stacktrace": "error.stack_trace",
+	"exception.escaped":    "error.escaped",
+	"timestamp":            "@timestamp",
+}
+
+// ecsTypeCoercions declares fields that must be coerced to a specific Kind
+// once they've been renamed to their ECS key, regardless of the Kind the
+// attribute originally arrived as.
+var ecsTypeCoercions = map[string]Kind{
+	"error.stack_trace": KindString,
+}
+
+// ApplyECS renames the document's fields from OpenTelemetry semantic
+// conventions to their Elastic Common Schema equivalents and applies the
+// declarative type coercions required by those ECS fields. overrides is
+// merged over the default mapping table, letting callers add or replace
+// individual key mappings; it may be nil. Unmapped keys pass through
+// unchanged.
+//
+// ApplyECS must run before Sort and Dedup, since renaming keys can change
+// their sort order and introduce new collisions (e.g. two OTel attributes
+// mapping to the same ECS field).
+//
+// This is a prerequisite for the elasticsearchexporter to target
+// ECS-indexed clusters: the exporter's document-building path needs to call
+// ApplyECS (behind a config flag, with the user-supplied mapping override
+// passed through from config) before Sort/Dedup/Serialize. That exporter
+// path isn't present in this checkout, so no caller wires it in yet; this
+// package only provides the transform itself.
+func (doc *Document) ApplyECS(overrides map[string]string) {
+	mapping := ecsAttributeKeys
+	if len(overrides) > 0 {
+		mapping = mergeECSAttributeKeys(overrides)
+	}
+
+	for i := range doc.fields {
+		fld := &doc.fields[i]
+		if ecsKey, ok := mapping[fld.key]; ok {
+			fld.key = ecsKey
+		}
+		if kind, ok := ecsTypeCoercions[fld.key]; ok {
+			fld.value = coerceValue(fld.value, kind)
+		}
+	}
+}
+
+// DocumentFromAttributesECS is DocumentFromAttributes followed by ApplyECS
+// using the default ECS mapping table.
+func DocumentFromAttributesECS(am pdata.AttributeMap) Document {
+	doc := DocumentFromAttributes(am)
+	doc.ApplyECS(nil)
+	return doc
+}
+
+func mergeECSAttributeKeys(overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(ecsAttributeKeys)+len(overrides))
+	for k, v := range ecsAttributeKeys {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
+// coerceValue converts v to the given Kind, if it isn't already. Only
+// conversions actually required by ecsTypeCoercions are implemented.
+func coerceValue(v Value, kind Kind) Value {
+	if v.kind == kind {
+		return v
+	}
+
+	switch kind {
+	case KindString:
+		return StringValue(stringifyValue(v))
+	default:
+		return v
+	}
+}
+
+func stringifyValue(v Value) string {
+	switch v.kind {
+	case KindString:
+		return v.str
+	case KindInt:
+		return fmt.Sprintf("%d", v.i)
+	case KindDouble:
+		return fmt.Sprintf("%g", v.d)
+	case KindBool:
+		return fmt.Sprintf("%t", v.b)
+	case KindTimestamp:
+		return v.ts.Format(tsLayout)
+	default:
+		return ""
+	}
+}
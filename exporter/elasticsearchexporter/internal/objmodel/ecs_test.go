@@ -0,0 +1,117 @@
+// Copyright 2021, OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objmodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+func TestDocument_ApplyECS(t *testing.T) {
+	tests := map[string]struct {
+		build     func() Document
+		overrides map[string]string
+		want      Document
+	}{
+		"renames known semantic-convention keys": {
+			build: func() Document {
+				return DocumentFromAttributesECS(pdata.NewAttributeMap().InitFromMap(map[string]pdata.AttributeValue{
+					"http.method":      pdata.NewAttributeValueString("GET"),
+					"http.status_code": pdata.NewAttributeValueInt(200),
+					"net.peer.ip":      pdata.NewAttributeValueString("10.0.0.1"),
+				}))
+			},
+			want: Document{[]field{
+				{"http.request.method", StringValue("GET")},
+				{"http.response.status_code", IntValue(200)},
+				{"source.ip", StringValue("10.0.0.1")},
+			}},
+		},
+		"service.name is left unchanged": {
+			build: func() Document {
+				return DocumentFromAttributesECS(pdata.NewAttributeMap().InitFromMap(map[string]pdata.AttributeValue{
+					"service.name": pdata.NewAttributeValueString("checkout"),
+				}))
+			},
+			want: Document{[]field{{"service.name", StringValue("checkout")}}},
+		},
+		"unknown keys pass through unchanged": {
+			build: func() Document {
+				return DocumentFromAttributesECS(pdata.NewAttributeMap().InitFromMap(map[string]pdata.AttributeValue{
+					"custom.attribute": pdata.NewAttributeValueString("value"),
+				}))
+			},
+			want: Document{[]field{{"custom.attribute", StringValue("value")}}},
+		},
+		"exception attributes map to error.*": {
+			build: func() Document {
+				return DocumentFromAttributesECS(pdata.NewAttributeMap().InitFromMap(map[string]pdata.AttributeValue{
+					"exception.type":    pdata.NewAttributeValueString("RuntimeError"),
+					"exception.message": pdata.NewAttributeValueString("boom"),
+				}))
+			},
+			want: Document{[]field{
+				{"error.message", StringValue("boom")},
+				{"error.type", StringValue("RuntimeError")},
+			}},
+		},
+		"exception.stacktrace is coerced to a string": {
+			build: func() (doc Document) {
+				doc.AddInt("exception.stacktrace", 42)
+				doc.ApplyECS(nil)
+				return doc
+			},
+			want: Document{[]field{{"error.stack_trace", StringValue("42")}}},
+		},
+		"timestamp is renamed to @timestamp": {
+			build: func() Document {
+				return DocumentFromAttributesECS(pdata.NewAttributeMap().InitFromMap(map[string]pdata.AttributeValue{
+					"timestamp": pdata.NewAttributeValueString("2021-01-01T00:00:00Z"),
+				}))
+			},
+			want: Document{[]field{{"@timestamp", StringValue("2021-01-01T00:00:00Z")}}},
+		},
+		"overrides are merged over the default mapping": {
+			build: func() (doc Document) {
+				doc.AddString("http.method", "GET")
+				doc.AddString("custom.key", "value")
+				doc.ApplyECS(map[string]string{"custom.key": "renamed.key"})
+				return doc
+			},
+			want: Document{[]field{
+				{"http.request.method", StringValue("GET")},
+				{"renamed.key", StringValue("value")},
+			}},
+		},
+		"overrides can replace a default mapping": {
+			build: func() (doc Document) {
+				doc.AddString("http.method", "GET")
+				doc.ApplyECS(map[string]string{"http.method": "custom.http.verb"})
+				return doc
+			},
+			want: Document{[]field{{"custom.http.verb", StringValue("GET")}}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			doc := test.build()
+			doc.Sort()
+			assert.Equal(t, test.want, doc)
+		})
+	}
+}
@@ -0,0 +1,545 @@
+// Copyright 2021, OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package objmodel defines a data model intermediate between pdata and the
+// JSON documents written to Elasticsearch. The model is optimized for
+// fast, memory efficient serialization into the Elasticsearch _bulk format.
+package objmodel
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-structform"
+	"github.com/elastic/go-structform/json"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// Document is an intermediate representation for converting OTLP data into
+// JSON documents that can be indexed by Elasticsearch. Document is
+// essentially a list of (key, value) pairs, where values can themselves be
+// nested Documents or arrays of values.
+//
+// A Document must be sorted and deduplicated (via Sort and Dedup) before it
+// can be serialized.
+type Document struct {
+	fields []field
+}
+
+// field is a (key, value) pair used to represent a field in a Document.
+type field struct {
+	key   string
+	value Value
+}
+
+// Kind represents the type of value held by a Value.
+type Kind uint8
+
+const (
+	// KindNil represents a null value.
+	KindNil Kind = iota
+	// KindBool represents a boolean value.
+	KindBool
+	// KindInt represents an integer value.
+	KindInt
+	// KindDouble represents a floating point value.
+	KindDouble
+	// KindString represents a string value.
+	KindString
+	// KindTimestamp represents a timestamp value.
+	KindTimestamp
+	// KindObject represents a nested Document.
+	KindObject
+	// KindArr represents an array of Value.
+	KindArr
+	// KindIgnore marks a field as removed by Dedup. Ignored fields are
+	// skipped during serialization.
+	KindIgnore
+)
+
+// Value is a mutation-free data type used to represent any kind of value
+// that can occur in a Document.
+type Value struct {
+	kind Kind
+
+	str string
+	i   int64
+	d   float64
+	b   bool
+	ts  time.Time
+
+	doc Document
+	arr []Value
+}
+
+// tsLayout is the timestamp format used when serializing timestamp values.
+const tsLayout = "2006-01-02T15:04:05.000000000Z"
+
+var (
+	nilValue    = Value{kind: KindNil}
+	ignoreValue = Value{kind: KindIgnore}
+)
+
+// StringValue creates a new value from a string.
+func StringValue(str string) Value { return Value{kind: KindString, str: str} }
+
+// IntValue creates a new value from an integer.
+func IntValue(i int64) Value { return Value{kind: KindInt, i: i} }
+
+// DoubleValue creates a new value from a double value.
+func DoubleValue(d float64) Value { return Value{kind: KindDouble, d: d} }
+
+// BoolValue creates a new value from a boolean.
+func BoolValue(b bool) Value { return Value{kind: KindBool, b: b} }
+
+// TimestampValue creates a new value from a time.Time.
+func TimestampValue(ts time.Time) Value { return Value{kind: KindTimestamp, ts: ts} }
+
+// ArrValue combines a list of values into an array value.
+func ArrValue(values ...Value) Value { return Value{kind: KindArr, arr: values} }
+
+// ValueFromAttribute converts a pdata.AttributeValue into a Value.
+func ValueFromAttribute(v pdata.AttributeValue) Value {
+	switch v.Type() {
+	case pdata.AttributeValueNULL:
+		return nilValue
+	case pdata.AttributeValueSTRING:
+		return StringValue(v.StringVal())
+	case pdata.AttributeValueINT:
+		return IntValue(v.IntVal())
+	case pdata.AttributeValueDOUBLE:
+		return DoubleValue(v.DoubleVal())
+	case pdata.AttributeValueBOOL:
+		return BoolValue(v.BoolVal())
+	case pdata.AttributeValueMAP:
+		return Value{kind: KindObject, doc: DocumentFromAttributes(v.MapVal())}
+	case pdata.AttributeValueARRAY:
+		arr := v.ArrayVal()
+		if arr.Len() == 0 {
+			return Value{kind: KindArr}
+		}
+		values := make([]Value, arr.Len())
+		for i := 0; i < arr.Len(); i++ {
+			values[i] = ValueFromAttribute(arr.At(i))
+		}
+		return Value{kind: KindArr, arr: values}
+	default:
+		return nilValue
+	}
+}
+
+// DocumentFromAttributes creates a new document from a set of attributes.
+func DocumentFromAttributes(am pdata.AttributeMap) Document {
+	return DocumentFromAttributesWithPath("", am)
+}
+
+// DocumentFromAttributesWithPath creates a new document from a set of
+// attributes, prefixing every key with path.
+func DocumentFromAttributesWithPath(path string, am pdata.AttributeMap) Document {
+	var doc Document
+	doc.AddAttributes(path, am)
+	return doc
+}
+
+// Add adds a field with the given key and value to the document.
+func (doc *Document) Add(key string, v Value) {
+	doc.fields = append(doc.fields, field{key: key, value: v})
+}
+
+// AddInt adds a field with the given key and integer value to the document.
+func (doc *Document) AddInt(key string, i int64) {
+	doc.Add(key, IntValue(i))
+}
+
+// AddString adds a field with the given key and string value to the document.
+func (doc *Document) AddString(key, str string) {
+	doc.Add(key, StringValue(str))
+}
+
+// AddAttribute converts an attribute value into a Value and adds it to the
+// document, flattening the attribute's contents into the document if it is
+// a map.
+func (doc *Document) AddAttribute(key string, v pdata.AttributeValue) {
+	switch v.Type() {
+	case pdata.AttributeValueNULL:
+		return
+	case pdata.AttributeValueMAP:
+		doc.AddAttributes(key, v.MapVal())
+	default:
+		doc.Add(key, ValueFromAttribute(v))
+	}
+}
+
+// AddAttributes expands and flattens all key-value pairs from the given
+// attribute map into the document, prefixing each key with key.
+func (doc *Document) AddAttributes(key string, am pdata.AttributeMap) {
+	am.ForEach(func(k string, v pdata.AttributeValue) {
+		doc.AddAttribute(flattenKey(key, k), v)
+	})
+}
+
+func flattenKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// Sort sorts all fields in the document by key name, recursing into nested
+// documents and arrays. Sort must be called before Dedup.
+func (doc *Document) Sort() {
+	sort.Stable(byKey(doc.fields))
+	for i := range doc.fields {
+		doc.fields[i].value.sort()
+	}
+}
+
+func (v *Value) sort() {
+	switch v.kind {
+	case KindObject:
+		v.doc.Sort()
+	case KindArr:
+		for i := range v.arr {
+			v.arr[i].sort()
+		}
+	}
+}
+
+type byKey []field
+
+func (f byKey) Len() int           { return len(f) }
+func (f byKey) Less(i, j int) bool { return f[i].key < f[j].key }
+func (f byKey) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+
+// DedupMode selects how Dedup resolves fields that share the exact same key.
+type DedupMode uint8
+
+const (
+	// DedupModeLastWins keeps the last occurrence of a duplicate key and
+	// ignores earlier ones. This is the zero value, preserving the
+	// historical, backward compatible behavior.
+	DedupModeLastWins DedupMode = iota
+	// DedupModeFirstWins keeps the first occurrence of a duplicate key and
+	// ignores later ones.
+	DedupModeFirstWins
+	// DedupModeErrorOnConflict fails Dedup as soon as a duplicate key is
+	// found.
+	DedupModeErrorOnConflict
+	// DedupModeMergeObjects merges the fields of duplicate keys whose values
+	// are all nested objects into a single object. Duplicates that are not
+	// all objects fall back to DedupModeLastWins.
+	DedupModeMergeObjects
+)
+
+// CollisionMode selects how Dedup resolves a primitive value that shares a
+// key with a namespace of nested fields (e.g. "namespace" alongside
+// "namespace.a").
+type CollisionMode uint8
+
+const (
+	// CollisionModeLiftToValue moves the primitive into a nested field named
+	// "value" (e.g. "namespace" becomes "namespace.value"). This is the zero
+	// value, preserving the historical, backward compatible behavior.
+	CollisionModeLiftToValue CollisionMode = iota
+	// CollisionModeDropPrimitive discards the primitive value, keeping only
+	// the nested fields.
+	CollisionModeDropPrimitive
+	// CollisionModeDropObject discards the nested fields, keeping only the
+	// primitive value.
+	CollisionModeDropObject
+	// CollisionModeError fails Dedup as soon as a collision is found.
+	CollisionModeError
+)
+
+// DedupPolicy configures how Dedup resolves duplicate and colliding fields.
+// The zero value reproduces the original Dedup behavior, so operators who
+// don't configure a policy see no change.
+//
+// DedupPolicy is meant to be set from the elasticsearchexporter's config
+// (e.g. as an optional `dedup_policy` / `collision_policy` pair of fields,
+// tri-stated the way other collector exporter configs are, so an unset
+// value keeps today's behavior). That config package isn't present in this
+// checkout, so the plumbing stops here at the objmodel API; wire it through
+// once exporter/elasticsearchexporter/config.go exists.
+type DedupPolicy struct {
+	Dedup     DedupMode
+	Collision CollisionMode
+}
+
+// Dedup removes fields with duplicate keys from the document according to
+// policy, assuming the document has already been sorted via Sort.
+func (doc *Document) Dedup(policy DedupPolicy) error {
+	if err := doc.resolvePrimitiveObjectConflicts(policy.Collision); err != nil {
+		return err
+	}
+	if err := doc.dedupAdjacentKeys(policy.Dedup); err != nil {
+		return err
+	}
+
+	for i := range doc.fields {
+		if err := doc.fields[i].value.dedup(policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Value) dedup(policy DedupPolicy) error {
+	switch v.kind {
+	case KindObject:
+		return v.doc.Dedup(policy)
+	case KindArr:
+		for i := range v.arr {
+			if err := v.arr[i].dedup(policy); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (doc *Document) resolvePrimitiveObjectConflicts(policy CollisionMode) error {
+	var renamed bool
+	for i := 0; i < len(doc.fields); i++ {
+		fld := &doc.fields[i]
+		if fld.value.kind == KindObject || fld.value.kind == KindArr || fld.value.kind == KindIgnore {
+			continue
+		}
+
+		j := i + 1
+		for j < len(doc.fields) && strings.HasPrefix(doc.fields[j].key, fld.key+".") {
+			j++
+		}
+		if j == i+1 {
+			continue
+		}
+
+		switch policy {
+		case CollisionModeDropPrimitive:
+			fld.value = ignoreValue
+		case CollisionModeDropObject:
+			for k := i + 1; k < j; k++ {
+				doc.fields[k].value = ignoreValue
+			}
+		case CollisionModeError:
+			return fmt.Errorf("objmodel: field %q collides with a nested object", fld.key)
+		default: // CollisionModeLiftToValue
+			fld.key += ".value"
+			renamed = true
+		}
+	}
+	if renamed {
+		doc.Sort()
+	}
+	return nil
+}
+
+func (doc *Document) dedupAdjacentKeys(mode DedupMode) error {
+	for i := 0; i < len(doc.fields); {
+		j := i + 1
+		for j < len(doc.fields) && doc.fields[j].key == doc.fields[i].key {
+			j++
+		}
+		if j-i > 1 {
+			switch mode {
+			case DedupModeFirstWins:
+				for k := i + 1; k < j; k++ {
+					doc.fields[k].value = ignoreValue
+				}
+			case DedupModeErrorOnConflict:
+				return fmt.Errorf("objmodel: duplicate field %q", doc.fields[i].key)
+			case DedupModeMergeObjects:
+				if allObjects(doc.fields[i:j]) {
+					merged := mergeDocs(doc.fields[i:j])
+					doc.fields[i].value = Value{kind: KindObject, doc: merged}
+					for k := i + 1; k < j; k++ {
+						doc.fields[k].value = ignoreValue
+					}
+				} else {
+					for k := i; k < j-1; k++ {
+						doc.fields[k].value = ignoreValue
+					}
+				}
+			default: // DedupModeLastWins
+				for k := i; k < j-1; k++ {
+					doc.fields[k].value = ignoreValue
+				}
+			}
+		}
+		i = j
+	}
+	return nil
+}
+
+func allObjects(fields []field) bool {
+	for i := range fields {
+		if fields[i].value.kind != KindObject {
+			return false
+		}
+	}
+	return true
+}
+
+func mergeDocs(fields []field) Document {
+	var merged Document
+	for i := range fields {
+		merged.fields = append(merged.fields, fields[i].value.doc.fields...)
+	}
+	merged.Sort()
+	return merged
+}
+
+// Serialize writes the JSON representation of the document to w. If dedot is
+// true, dotted field names are regrouped into nested JSON objects instead of
+// being emitted as flat, dot-delimited keys. The document must be sorted and
+// deduplicated (via Sort and Dedup) before being serialized.
+func (doc *Document) Serialize(w io.Writer, dedot bool) error {
+	v := json.NewVisitor(w)
+	return doc.iterJSON(v, dedot)
+}
+
+func (doc *Document) iterJSON(w structform.ExtVisitor, dedot bool) error {
+	if dedot {
+		return iterJSONDedotFields(w, doc.fields)
+	}
+
+	if !hasVisibleField(doc.fields) {
+		return w.OnNil()
+	}
+
+	if err := w.OnObjectStart(-1, structform.AnyType); err != nil {
+		return err
+	}
+	for i := range doc.fields {
+		fld := &doc.fields[i]
+		if fld.value.kind == KindIgnore {
+			continue
+		}
+		if err := w.OnKey(fld.key); err != nil {
+			return err
+		}
+		if err := fld.value.iterJSON(w, dedot); err != nil {
+			return err
+		}
+	}
+	return w.OnObjectFinished()
+}
+
+// iterJSONDedotFields serializes a sorted, deduplicated list of fields,
+// regrouping contiguous dotted keys that share a common prefix into nested
+// JSON objects (e.g. "namespace.a" and "namespace.b" become
+// "namespace": {"a": ..., "b": ...}).
+func iterJSONDedotFields(w structform.ExtVisitor, fields []field) error {
+	if !hasVisibleField(fields) {
+		return w.OnNil()
+	}
+
+	if err := w.OnObjectStart(-1, structform.AnyType); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(fields); {
+		fld := &fields[i]
+		if fld.value.kind == KindIgnore {
+			i++
+			continue
+		}
+
+		if dot := strings.IndexByte(fld.key, '.'); dot >= 0 {
+			prefix := fld.key[:dot]
+
+			j := i
+			for j < len(fields) && strings.HasPrefix(fields[j].key, prefix+".") {
+				j++
+			}
+
+			nested := make([]field, j-i)
+			for k := range nested {
+				nested[k] = fields[i+k]
+				nested[k].key = nested[k].key[dot+1:]
+			}
+
+			if err := w.OnKey(prefix); err != nil {
+				return err
+			}
+			if err := iterJSONDedotFields(w, nested); err != nil {
+				return err
+			}
+
+			i = j
+			continue
+		}
+
+		if err := w.OnKey(fld.key); err != nil {
+			return err
+		}
+		if err := fld.value.iterJSON(w, true); err != nil {
+			return err
+		}
+		i++
+	}
+
+	return w.OnObjectFinished()
+}
+
+func hasVisibleField(fields []field) bool {
+	for i := range fields {
+		if fields[i].value.kind != KindIgnore {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Value) iterJSON(w structform.ExtVisitor, dedot bool) error {
+	switch v.kind {
+	case KindNil, KindIgnore:
+		return w.OnNil()
+	case KindBool:
+		return w.OnBool(v.b)
+	case KindInt:
+		return w.OnInt64(v.i)
+	case KindDouble:
+		if math.IsNaN(v.d) || math.IsInf(v.d, 0) {
+			return w.OnNil()
+		}
+		return w.OnFloat64(v.d)
+	case KindString:
+		return w.OnString(v.str)
+	case KindTimestamp:
+		return w.OnString(v.ts.Format(tsLayout))
+	case KindArr:
+		return v.iterJSONArray(w, dedot)
+	case KindObject:
+		return v.doc.iterJSON(w, dedot)
+	default:
+		return w.OnNil()
+	}
+}
+
+func (v *Value) iterJSONArray(w structform.ExtVisitor, dedot bool) error {
+	if err := w.OnArrayStart(-1, structform.AnyType); err != nil {
+		return err
+	}
+	for i := range v.arr {
+		if err := v.arr[i].iterJSON(w, dedot); err != nil {
+			return err
+		}
+	}
+	return w.OnArrayFinished()
+}
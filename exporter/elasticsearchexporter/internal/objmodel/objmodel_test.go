@@ -133,8 +133,10 @@ func TestDocument_Sort(t *testing.T) {
 
 func TestObjectModel_Dedup(t *testing.T) {
 	tests := map[string]struct {
-		build func() Document
-		want  Document
+		build   func() Document
+		policy  DedupPolicy
+		want    Document
+		wantErr bool
 	}{
 		"no duplicates": {
 			build: func() (doc Document) {
@@ -212,13 +214,88 @@ func TestObjectModel_Dedup(t *testing.T) {
 			},
 			want: Document{[]field{{"namespace.a", IntValue(2)}, {"namespace.value", ignoreValue}, {"namespace.value", IntValue(3)}}},
 		},
+		"DedupModeFirstWins keeps the first value": {
+			build: func() (doc Document) {
+				doc.AddInt("a", 1)
+				doc.AddInt("c", 3)
+				doc.AddInt("a", 2)
+				return doc
+			},
+			policy: DedupPolicy{Dedup: DedupModeFirstWins},
+			want:   Document{[]field{{"a", IntValue(1)}, {"a", ignoreValue}, {"c", IntValue(3)}}},
+		},
+		"DedupModeErrorOnConflict fails on duplicate keys": {
+			build: func() (doc Document) {
+				doc.AddInt("a", 1)
+				doc.AddInt("a", 2)
+				return doc
+			},
+			policy:  DedupPolicy{Dedup: DedupModeErrorOnConflict},
+			wantErr: true,
+		},
+		"DedupModeMergeObjects merges duplicate objects": {
+			build: func() (doc Document) {
+				var a, b Document
+				a.AddInt("x", 1)
+				b.AddInt("y", 2)
+				doc.Add("obj", Value{kind: KindObject, doc: a})
+				doc.Add("obj", Value{kind: KindObject, doc: b})
+				return doc
+			},
+			policy: DedupPolicy{Dedup: DedupModeMergeObjects},
+			want: Document{[]field{
+				{"obj", Value{kind: KindObject, doc: Document{[]field{{"x", IntValue(1)}, {"y", IntValue(2)}}}}},
+				{"obj", ignoreValue},
+			}},
+		},
+		"DedupModeMergeObjects falls back to last wins for non-objects": {
+			build: func() (doc Document) {
+				doc.AddInt("a", 1)
+				doc.AddInt("a", 2)
+				return doc
+			},
+			policy: DedupPolicy{Dedup: DedupModeMergeObjects},
+			want:   Document{[]field{{"a", ignoreValue}, {"a", IntValue(2)}}},
+		},
+		"CollisionModeDropPrimitive discards the primitive": {
+			build: func() (doc Document) {
+				doc.AddInt("namespace", 1)
+				doc.AddInt("namespace.a", 2)
+				return doc
+			},
+			policy: DedupPolicy{Collision: CollisionModeDropPrimitive},
+			want:   Document{[]field{{"namespace", ignoreValue}, {"namespace.a", IntValue(2)}}},
+		},
+		"CollisionModeDropObject discards the nested fields": {
+			build: func() (doc Document) {
+				doc.AddInt("namespace", 1)
+				doc.AddInt("namespace.a", 2)
+				return doc
+			},
+			policy: DedupPolicy{Collision: CollisionModeDropObject},
+			want:   Document{[]field{{"namespace", IntValue(1)}, {"namespace.a", ignoreValue}}},
+		},
+		"CollisionModeError fails on collision": {
+			build: func() (doc Document) {
+				doc.AddInt("namespace", 1)
+				doc.AddInt("namespace.a", 2)
+				return doc
+			},
+			policy:  DedupPolicy{Collision: CollisionModeError},
+			wantErr: true,
+		},
 	}
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			doc := test.build()
 			doc.Sort()
-			doc.Dedup()
+			err := doc.Dedup(test.policy)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
 			assert.Equal(t, test.want, doc)
 		})
 	}
@@ -329,3 +406,66 @@ func TestValue_Serialize(t *testing.T) {
 		})
 	}
 }
+
+func TestDocument_SerializeDedot(t *testing.T) {
+	tests := map[string]struct {
+		build func() Document
+		want  string
+	}{
+		"dotted keys are regrouped into a nested object": {
+			build: func() (doc Document) {
+				doc.AddInt("namespace.a", 1)
+				doc.AddInt("namespace.b", 2)
+				doc.AddString("toplevel", "x")
+				return doc
+			},
+			want: `{"namespace":{"a":1,"b":2},"toplevel":"x"}`,
+		},
+		"lifted namespace.value ends up nested under namespace": {
+			build: func() (doc Document) {
+				doc.AddInt("namespace", 1)
+				doc.AddInt("namespace.a", 2)
+				return doc
+			},
+			want: `{"namespace":{"a":2,"value":1}}`,
+		},
+		"NaN and Inf collapse to null inside a nested object": {
+			build: func() (doc Document) {
+				doc.Add("namespace.inf", DoubleValue(math.Inf(1)))
+				doc.Add("namespace.nan", DoubleValue(math.NaN()))
+				return doc
+			},
+			want: `{"namespace":{"inf":null,"nan":null}}`,
+		},
+		"an empty nested object collapses to null": {
+			build: func() (doc Document) {
+				doc.Add("empty", Value{kind: KindObject})
+				doc.AddString("toplevel", "x")
+				return doc
+			},
+			want: `{"empty":null,"toplevel":"x"}`,
+		},
+		"array elements are dedotted too": {
+			build: func() (doc Document) {
+				var elem Document
+				elem.AddInt("a.x", 1)
+				elem.AddInt("a.y", 2)
+				doc.Add("arr", ArrValue(Value{kind: KindObject, doc: elem}))
+				return doc
+			},
+			want: `{"arr":[{"a":{"x":1,"y":2}}]}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			doc := test.build()
+			doc.Sort()
+			require.NoError(t, doc.Dedup(DedupPolicy{}))
+
+			var buf strings.Builder
+			require.NoError(t, doc.Serialize(&buf, true))
+			assert.Equal(t, test.want, buf.String())
+		})
+	}
+}